@@ -26,29 +26,140 @@ Examples:
 	Or the first 4 columns (in bash):
 	$ netstat | cl {1..4} -t
 
+	Grab a range of columns, or everything from column 3 onward:
+	$ netstat | cl 1-3 -t
+	$ netstat | cl 3- -t
+
+	Grab the last column, however wide the row is:
+	$ netstat | cl -1 -t
+
+	Grab everything except columns 2 through 4:
+	$ netstat | cl ^2-4 -t
+
+	Grab everything except the columns you list (same as prefixing each with ^):
+	$ netstat | cl --complement 1 2 -t
+
+	Select columns by header name instead of index (-H), mixing names and indexes,
+	and matching header names by regex:
+	$ ps | cl -H PID COMMAND
+	$ docker ps | cl -H 'CPU.*' --print-headers
+
+	Pull a column out of a real CSV file, respecting quoting, and re-emit CSV:
+	$ cl -H name --input-format csv --output-format csv <accounts.csv
+
+	Select fields out of line-delimited JSON by key, including nested keys with
+	dotted paths, and emit JSON objects back out:
+	$ tail -f app.ndjson | cl user.id level msg --input-format ndjson --output-format ndjson
+
+	Parse aligned columns by character position instead of by separator, which
+	handles ps-style output where a field like COMMAND may contain spaces:
+	$ ps aux | cl -H -w auto COMMAND
+	$ df -h | cl -w 1-20,21-26,45- 1 3
+
+	Treat multi-line log entries (e.g. stack traces) as single records by
+	splitting on an explicit record-separator line instead of a plain newline,
+	and raise the scanner's buffer for very long lines:
+	$ cl 1 --record-separator '\n===\n' --max-record-size 1048576 <app.log
+	$ git log | cl --paragraph -s '\n' 1
+
+	Normalize values inline with per-column transforms before printing:
+	$ cl 1 2 -x 2:upper -x 3:hash8 -x 4:date2unix
+	$ cl 1 -x '1:regex:s/\.log$/.txt/'
+
+	Reduce a stream to aggregate stats instead of printing every row:
+	$ cl 2 --sum 2 --count --uniq <sales.tsv
+
 Options:
   -i    ignore the header row (first row)
   -s string
         a character or regex to split lines (default: whitespace)
   -t    use tabs as separator (alias of -s \t)
+  -H    treat the first row as headers and select columns by name instead of index
+  -w string
+        parse fixed-width columns at character ranges (e.g. 1-10,11-20,21-),
+        or "auto" to detect column boundaries from the header row
+  --widths string
+        alias of -w
+  -x string
+        apply a transform to a column, e.g. 2:upper or 4:regex:s/foo/bar/ (repeatable)
+  --transform string
+        alias of -x
+  --complement
+        print every column except the ones listed
+  --print-headers
+        print the resolved header row even when -i is also given
+  --input-format string
+        auto, whitespace, csv, tsv, json, or ndjson (default: auto)
+  --output-format string
+        auto, whitespace, csv, tsv, json, or ndjson (default: auto)
+  --record-separator string
+        a string or regex delimiting logical records (default: \n)
+  --max-record-size int
+        maximum size in bytes of a single record (default: 65536)
+  --paragraph
+        treat blank-line-separated blocks as single records
+  --count
+        print the number of rows read
+  --sum string
+        print the numeric sum of the given column
+  --min string
+        print the numeric minimum of the given column
+  --max string
+        print the numeric maximum of the given column
+  --uniq
+        print only the distinct selected rows
 */
 package main
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Flag definitions
 var separator string
 var useTabSeparator bool
 var ignoreHeaderRow bool
+var complement bool
+var headerMode bool
+var printHeaders bool
+var inputFormat string
+var outputFormat string
+var widthsSpec string
+var recordSeparator string
+var maxRecordSize int
+var paragraphMode bool
+var transformArgs stringListFlag
+var countAgg bool
+var sumCol string
+var minCol string
+var maxCol string
+var uniqAgg bool
+
+// stringListFlag is a flag.Value that collects every occurrence of a
+// repeatable string flag, e.g. -x 2:upper -x 3:trim.
+type stringListFlag []string
+
+func (f *stringListFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringListFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
 
 // Use local variables and Reader/Writer interfaces so we can substitute these for testing
 var stdin io.Reader
@@ -60,6 +171,23 @@ func init() {
 	flag.StringVar(&separator, "s", "", "a character or regex to split lines (default: whitespace)")
 	flag.BoolVar(&useTabSeparator, "t", false, "use tabs as separator (alias of -s \\t)")
 	flag.BoolVar(&ignoreHeaderRow, "i", false, "ignore the header row (first row)")
+	flag.BoolVar(&complement, "complement", false, "print every column except the ones listed (like cut --complement)")
+	flag.BoolVar(&headerMode, "H", false, "treat the first row as headers and select columns by name instead of index")
+	flag.BoolVar(&printHeaders, "print-headers", false, "print the resolved header row even when -i is also given")
+	flag.StringVar(&inputFormat, "input-format", "auto", "input format: auto, whitespace, csv, tsv, json, or ndjson")
+	flag.StringVar(&outputFormat, "output-format", "auto", "output format: auto, whitespace, csv, tsv, json, or ndjson")
+	flag.StringVar(&widthsSpec, "w", "", `parse fixed-width columns at character ranges (e.g. 1-10,11-20,21-), or "auto" to detect column boundaries from the header row`)
+	flag.StringVar(&widthsSpec, "widths", "", "alias of -w")
+	flag.StringVar(&recordSeparator, "record-separator", "\n", "a string or regex delimiting logical records")
+	flag.IntVar(&maxRecordSize, "max-record-size", 0, "maximum size in bytes of a single record (default: 65536)")
+	flag.BoolVar(&paragraphMode, "paragraph", false, "treat blank-line-separated blocks as single records")
+	flag.Var(&transformArgs, "x", "apply a transform to a column, e.g. 2:upper or 4:regex:s/foo/bar/ (repeatable)")
+	flag.Var(&transformArgs, "transform", "alias of -x")
+	flag.BoolVar(&countAgg, "count", false, "print the number of rows read")
+	flag.StringVar(&sumCol, "sum", "", "print the numeric sum of the given column")
+	flag.StringVar(&minCol, "min", "", "print the numeric minimum of the given column")
+	flag.StringVar(&maxCol, "max", "", "print the numeric maximum of the given column")
+	flag.BoolVar(&uniqAgg, "uniq", false, "print only the distinct selected rows")
 
 	flag.Usage = func() {
 		fmt.Printf(`Usage: cl [options...] <column_indexes...>
@@ -90,6 +218,49 @@ Examples:
 	Or the first 4 columns (in bash):
 	$ netstat | cl {1..4} -t
 
+	Grab a range of columns, or everything from column 3 onward:
+	$ netstat | cl 1-3 -t
+	$ netstat | cl 3- -t
+
+	Grab the last column, however wide the row is:
+	$ netstat | cl -1 -t
+
+	Grab everything except columns 2 through 4:
+	$ netstat | cl ^2-4 -t
+
+	Grab everything except the columns you list (same as prefixing each with ^):
+	$ netstat | cl --complement 1 2 -t
+
+	Select columns by header name instead of index (-H), mixing names and indexes,
+	and matching header names by regex:
+	$ ps | cl -H PID COMMAND
+	$ docker ps | cl -H 'CPU.*' --print-headers
+
+	Pull a column out of a real CSV file, respecting quoting, and re-emit CSV:
+	$ cl -H name --input-format csv --output-format csv <accounts.csv
+
+	Select fields out of line-delimited JSON by key, including nested keys with
+	dotted paths, and emit JSON objects back out:
+	$ tail -f app.ndjson | cl user.id level msg --input-format ndjson --output-format ndjson
+
+	Parse aligned columns by character position instead of by separator, which
+	handles ps-style output where a field like COMMAND may contain spaces:
+	$ ps aux | cl -H -w auto COMMAND
+	$ df -h | cl -w 1-20,21-26,45- 1 3
+
+	Treat multi-line log entries (e.g. stack traces) as single records by
+	splitting on an explicit record-separator line instead of a plain newline,
+	and raise the scanner's buffer for very long lines:
+	$ cl 1 --record-separator '\n===\n' --max-record-size 1048576 <app.log
+	$ git log | cl --paragraph -s '\n' 1
+
+	Normalize values inline with per-column transforms before printing:
+	$ cl 1 2 -x 2:upper -x 3:hash8 -x 4:date2unix
+	$ cl 1 -x '1:regex:s/\.log$/.txt/'
+
+	Reduce a stream to aggregate stats instead of printing every row:
+	$ cl 2 --sum 2 --count --uniq <sales.tsv
+
 Options:
 `)
 		flag.PrintDefaults()
@@ -102,35 +273,752 @@ Options:
 }
 
 func main() {
+	flag.CommandLine.Parse(reorderArgs(os.Args[1:]))
 	exitFunc(run())
 }
 
-// run executes the command and returns a shell return code
-func run() int {
-	flag.Parse()
+// columnSpec is a single column, range of columns, or header-name pattern as
+// specified on the command line, e.g. "2", "-1", "3-5", "3-" (open-ended), or
+// "PID" / "CPU.*" (header mode only). start and end are 1-indexed and
+// inclusive; negative values count backward from the last column of the row
+// (-1 is the last column). end == 0 means "to the end of the row". pattern is
+// set instead of start/end when the spec is a header-name match.
+type columnSpec struct {
+	start   int64
+	end     int64
+	pattern *regexp.Regexp
+}
 
-	// Figure out what columns (1-indexed) the user wants and validate them
-	//
+// parseColumnSpec parses a single column argument (with any leading exclusion
+// marker already stripped) into a columnSpec. If allowKeyMatch is true, an
+// argument that isn't a number or range is treated as a regex to match against
+// header names or JSON keys instead of being an error.
+func parseColumnSpec(arg string, allowKeyMatch bool) (columnSpec, error) {
+	if n, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		if n == 0 {
+			return columnSpec{}, fmt.Errorf("column indexes must not be 0")
+		}
+		return columnSpec{start: n, end: n}, nil
+	}
+
+	dash := strings.IndexByte(arg, '-')
+	if dash > 0 {
+		start, err := strconv.ParseInt(arg[:dash], 10, 64)
+		if err == nil && start >= 1 {
+			tail := arg[dash+1:]
+			if tail == "" {
+				return columnSpec{start: start, end: 0}, nil
+			}
+			if end, err := strconv.ParseInt(tail, 10, 64); err == nil && end >= 1 {
+				return columnSpec{start: start, end: end}, nil
+			}
+		}
+	}
+
+	if !allowKeyMatch {
+		return columnSpec{}, fmt.Errorf("could not parse %q as a column, range, or negative index", arg)
+	}
+
+	pattern, err := regexp.Compile("^(?:" + arg + ")$")
+	if err != nil {
+		return columnSpec{}, fmt.Errorf("could not parse %q as a column index or header name pattern: %v", arg, err)
+	}
+	return columnSpec{pattern: pattern}, nil
+}
+
+// resolve expands a columnSpec into concrete 1-indexed column numbers for a
+// row of the given width, in ascending order, skipping any that fall outside
+// the row. headers is the header row resolved in -H mode, or nil otherwise;
+// it is only consulted for header-name patterns.
+func (s columnSpec) resolve(rowLen int, headers []string) []int {
+	if s.pattern != nil {
+		var out []int
+		for i, h := range headers {
+			if i >= rowLen {
+				break
+			}
+			if s.pattern.MatchString(h) {
+				out = append(out, i+1)
+			}
+		}
+		return out
+	}
+
+	start, end := s.start, s.end
+	if start < 0 {
+		start = int64(rowLen) + start + 1
+		end = start
+	}
+	if end == 0 {
+		end = int64(rowLen)
+	}
+
+	var out []int
+	for i := start; i <= end; i++ {
+		if i >= 1 && i <= int64(rowLen) {
+			out = append(out, int(i))
+		}
+	}
+	return out
+}
 
-	columns := map[int64]struct{}{}
-	for _, arg := range flag.Args() {
-		c, err := strconv.ParseInt(arg, 10, 64)
+// ColumnSelector resolves the column arguments the user gave on the command
+// line into the concrete set of column indexes to print for a given row. It is
+// its own type, rather than a plain set of indexes, because ranges, negative
+// indexes, and exclusions can only be resolved once the width of a particular
+// row is known (row width may vary, e.g. ps output with a ragged last column).
+type ColumnSelector struct {
+	includes   []columnSpec
+	excludes   []columnSpec
+	complement bool
+}
+
+// newColumnSelector builds a ColumnSelector from the command-line column
+// arguments. Arguments prefixed with ^ or ! are exclusions. If complement is
+// true, or only exclusions were given, the selector starts from "every column".
+// If allowKeyMatch is true, arguments that aren't numbers or ranges are
+// treated as regexes matched against header names or JSON keys (e.g. "PID",
+// "CPU.*", or "user.id").
+func newColumnSelector(args []string, complement bool, allowKeyMatch bool) (*ColumnSelector, error) {
+	cs := &ColumnSelector{complement: complement}
+	for _, arg := range args {
+		exclude := false
+		switch {
+		case strings.HasPrefix(arg, "^"):
+			exclude = true
+			arg = arg[1:]
+		case strings.HasPrefix(arg, "!"):
+			exclude = true
+			arg = arg[1:]
+		}
+
+		spec, err := parseColumnSpec(arg, allowKeyMatch)
 		if err != nil {
-			fmt.Fprintf(stderr, "ERROR: failed to parse argument %q: %v\n", arg, err)
-			return 1
+			return nil, err
 		}
-		if c < 1 {
-			fmt.Fprintf(stderr, "ERROR: argument %q is invalid, column indexes must be positive numbers\n", c)
-			return 1
+		if exclude {
+			cs.excludes = append(cs.excludes, spec)
+		} else {
+			cs.includes = append(cs.includes, spec)
+		}
+	}
+
+	if len(cs.includes) == 0 && len(cs.excludes) > 0 {
+		cs.includes = append(cs.includes, columnSpec{start: 1, end: 0})
+	}
+
+	return cs, nil
+}
+
+// Resolve returns the column indexes to print for a row of the given width, in
+// the order they should be printed. Explicit selections are emitted in the
+// order the user specified them; the complement of a selection (or of an
+// exclusion-only selector) is emitted in ascending order since it has no
+// user-specified order of its own. headers is the resolved header row in -H
+// mode, or nil otherwise.
+func (cs *ColumnSelector) Resolve(rowLen int, headers []string) []int {
+	excluded := map[int]bool{}
+	for _, spec := range cs.excludes {
+		for _, i := range spec.resolve(rowLen, headers) {
+			excluded[i] = true
+		}
+	}
+
+	var selected []int
+	seen := map[int]bool{}
+	for _, spec := range cs.includes {
+		for _, i := range spec.resolve(rowLen, headers) {
+			if excluded[i] || seen[i] {
+				continue
+			}
+			seen[i] = true
+			selected = append(selected, i)
+		}
+	}
+
+	if !cs.complement {
+		return selected
+	}
+
+	inSelection := map[int]bool{}
+	for _, i := range selected {
+		inSelection[i] = true
+	}
+	var result []int
+	for i := 1; i <= rowLen; i++ {
+		if !inSelection[i] {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// validFormats are the recognized values for --input-format/--output-format.
+var validFormats = map[string]bool{
+	"auto": true, "whitespace": true, "csv": true, "tsv": true, "json": true, "ndjson": true,
+}
+
+// isPositionalFormat reports whether an input format splits a row into
+// fields by position (whitespace/csv/tsv), as opposed to json/ndjson where
+// fields are addressed by key and each row carries its own header names.
+func isPositionalFormat(format string) bool {
+	return format == "auto" || format == "whitespace" || format == "csv" || format == "tsv"
+}
+
+// rowSource yields successive rows of input. ok is false once input is
+// exhausted. headers is non-nil only when the row itself carries its own
+// field names (flattened JSON keys); it does not imply a header line was
+// consumed from the stream.
+type rowSource func() (fields []string, headers []string, ok bool, err error)
+
+// newDelimitedRowSource builds a rowSource that splits each line of s into
+// fields with splitFields, for the whitespace/auto input formats.
+func newDelimitedRowSource(s *bufio.Scanner, splitFields func(string) []string) rowSource {
+	return func() ([]string, []string, bool, error) {
+		if !s.Scan() {
+			return nil, nil, false, s.Err()
+		}
+		return splitFields(s.Text()), nil, true, nil
+	}
+}
+
+// newCSVRowSource builds a rowSource that reads records from r, for the csv
+// and tsv input formats.
+func newCSVRowSource(r *csv.Reader) rowSource {
+	return func() ([]string, []string, bool, error) {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil, nil, false, nil
+		}
+		if err != nil {
+			return nil, nil, false, err
+		}
+		return record, nil, true, nil
+	}
+}
+
+// newNDJSONRowSource builds a rowSource that decodes each line of s as a JSON
+// value and flattens it into fields and dotted-path headers.
+func newNDJSONRowSource(s *bufio.Scanner) rowSource {
+	return func() ([]string, []string, bool, error) {
+		if !s.Scan() {
+			return nil, nil, false, s.Err()
+		}
+		var v interface{}
+		if err := json.Unmarshal(s.Bytes(), &v); err != nil {
+			return nil, nil, false, fmt.Errorf("could not parse line as JSON: %v", err)
+		}
+		headers, fields := flattenJSON(v)
+		return fields, headers, true, nil
+	}
+}
+
+// newJSONRowSource decodes all of r as a single JSON array and returns a
+// rowSource that yields its elements, flattened the same way as NDJSON rows.
+func newJSONRowSource(r io.Reader) (rowSource, error) {
+	var rows []interface{}
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("could not parse input as a JSON array: %v", err)
+	}
+	i := 0
+	return func() ([]string, []string, bool, error) {
+		if i >= len(rows) {
+			return nil, nil, false, nil
+		}
+		headers, fields := flattenJSON(rows[i])
+		i++
+		return fields, headers, true, nil
+	}, nil
+}
+
+// flattenJSON turns a decoded JSON value into parallel slices of dotted-path
+// keys and stringified values, so it can be addressed with the same
+// ColumnSelector used for positional and header-named columns. Object keys
+// are visited in sorted order so that selection and output are deterministic.
+// A non-object value (including a JSON array row) is returned as a single
+// field with no header.
+func flattenJSON(v interface{}) (headers []string, fields []string) {
+	var walk func(v interface{}, prefix string)
+	walk = func(v interface{}, prefix string) {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			headers = append(headers, prefix)
+			fields = append(fields, jsonValueToString(v))
+			return
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			walk(obj[k], path)
+		}
+	}
+	walk(v, "")
+	if len(headers) == 1 && headers[0] == "" {
+		headers = nil
+	}
+	return headers, fields
+}
+
+// jsonValueToString renders a decoded JSON scalar, array, or null as the
+// string that should be printed for it.
+func jsonValueToString(v interface{}) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return vv
+	default:
+		b, _ := json.Marshal(vv)
+		return string(b)
+	}
+}
+
+// rowObject builds the object to emit for a row in json/ndjson output mode,
+// keyed by header name when headers are available, falling back to the
+// 1-indexed column number otherwise.
+func rowObject(fields []string, indexes []int, headers []string) map[string]string {
+	obj := make(map[string]string, len(indexes))
+	for _, idx := range indexes {
+		key := strconv.Itoa(idx)
+		if idx-1 < len(headers) {
+			key = headers[idx-1]
+		}
+		obj[key] = fields[idx-1]
+	}
+	return obj
+}
+
+// charRange is a 0-indexed, half-open span of character positions within a
+// line, used by fixed-width (-w) parsing. end == -1 means "to the end of the
+// line".
+type charRange struct {
+	start int
+	end   int
+}
+
+// parseWidthSpec parses a comma-separated list of 1-indexed, inclusive
+// character ranges such as "1-10,11-20,21-" into charRanges.
+func parseWidthSpec(spec string) ([]charRange, error) {
+	var ranges []charRange
+	for _, part := range strings.Split(spec, ",") {
+		dash := strings.IndexByte(part, '-')
+		if dash <= 0 {
+			return nil, fmt.Errorf("could not parse %q as a character range", part)
+		}
+
+		start, err := strconv.Atoi(part[:dash])
+		if err != nil || start < 1 {
+			return nil, fmt.Errorf("could not parse %q as a character range: start must be a positive number", part)
+		}
+
+		tail := part[dash+1:]
+		if tail == "" {
+			ranges = append(ranges, charRange{start: start - 1, end: -1})
+			continue
+		}
+
+		end, err := strconv.Atoi(tail)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("could not parse %q as a character range: end must be a number >= start", part)
+		}
+		ranges = append(ranges, charRange{start: start - 1, end: end})
+	}
+	return ranges, nil
+}
+
+// detectWidths scans a header line for runs of non-space characters and
+// returns a charRange for each one: from where the run starts to where the
+// next run starts, so that internal spaces (e.g. in a ps COMMAND column)
+// stay with their field. The last range is left open-ended.
+func detectWidths(header string) []charRange {
+	runes := []rune(header)
+	var starts []int
+	inRun := false
+	for i, r := range runes {
+		if r != ' ' {
+			if !inRun {
+				starts = append(starts, i)
+				inRun = true
+			}
+		} else {
+			inRun = false
+		}
+	}
+
+	ranges := make([]charRange, len(starts))
+	for i, s := range starts {
+		end := -1
+		if i < len(starts)-1 {
+			end = starts[i+1]
+		}
+		ranges[i] = charRange{start: s, end: end}
+	}
+	return ranges
+}
+
+// sliceByWidths splits a line into fields at the given character ranges,
+// trimming surrounding padding from each. Ranges that fall past the end of a
+// shorter line yield a truncated or empty field rather than an error. Fields
+// are always cut at the range boundaries: a value longer than its range is
+// not kept whole, and the characters past the range's end land in the next
+// field.
+func sliceByWidths(line string, ranges []charRange) []string {
+	runes := []rune(line)
+	fields := make([]string, len(ranges))
+	for i, r := range ranges {
+		start, end := r.start, r.end
+		if end < 0 || end > len(runes) {
+			end = len(runes)
+		}
+		if start > len(runes) {
+			start = len(runes)
+		}
+		if start > end {
+			start = end
+		}
+		fields[i] = strings.TrimSpace(string(runes[start:end]))
+	}
+	return fields
+}
+
+// newWidthRowSource builds a rowSource that slices each line from s at the
+// given ranges. firstLine/hasFirstLine carry a line already consumed from s
+// while auto-detecting widths, so it isn't lost.
+func newWidthRowSource(s *bufio.Scanner, ranges []charRange, firstLine string, hasFirstLine bool) rowSource {
+	return func() ([]string, []string, bool, error) {
+		line := firstLine
+		if hasFirstLine {
+			hasFirstLine = false
+		} else {
+			if !s.Scan() {
+				return nil, nil, false, s.Err()
+			}
+			line = s.Text()
+		}
+		return sliceByWidths(line, ranges), nil, true, nil
+	}
+}
+
+// newRecordSplitter builds a bufio.SplitFunc that divides input into logical
+// records, so records that span multiple physical lines (e.g. stack traces,
+// multi-line log entries) can be treated as a single row before column
+// splitting is applied. If paragraph is true, records are separated by blank
+// lines and sep is ignored. Otherwise sep is matched as a regex; "\n" is
+// handled with the standard, more efficient bufio.ScanLines.
+func newRecordSplitter(sep string, paragraph bool) (bufio.SplitFunc, error) {
+	if paragraph {
+		return splitParagraphs, nil
+	}
+	if sep == "\n" {
+		return bufio.ScanLines, nil
+	}
+
+	re, err := regexp.Compile(sep)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %q as a regular expression: %v", sep, err)
+	}
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if loc := re.FindIndex(data); loc != nil && (atEOF || loc[1] < len(data)) {
+			return loc[1], data[:loc[0]], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}, nil
+}
+
+// splitParagraphs is a bufio.SplitFunc for --paragraph mode: records are
+// separated by one or more blank lines.
+func splitParagraphs(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+		end := i + 2
+		for end < len(data) && data[end] == '\n' {
+			end++
+		}
+		if end < len(data) || atEOF {
+			return end, bytes.TrimRight(data[:i], "\n"), nil
+		}
+	}
+	if atEOF {
+		return len(data), bytes.TrimRight(data, "\n"), nil
+	}
+	return 0, nil, nil
+}
+
+// newScanner builds a bufio.Scanner over r using splitFunc, raising its
+// buffer to fit maxRecordSize when set (the default bufio.Scanner buffer is
+// 64KB, too small for some long log lines).
+func newScanner(r io.Reader, splitFunc bufio.SplitFunc, maxRecordSize int) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Split(splitFunc)
+	if maxRecordSize > 0 {
+		s.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxRecordSize)
+	}
+	return s
+}
+
+// dateRe matches the date portion of a date2unix transform input, e.g.
+// "2026-07-25 14:03:01" or "2026-07-25-14:03:01".
+var dateRe = regexp.MustCompile(`(\d{4}-\d{2}-\d{2})[ -](\d{2}:\d{2}:\d{2})`)
+
+// transformDate2Unix converts a value containing a date like
+// "2026-07-25 14:03:01" into Unix microseconds. Values that don't match the
+// expected pattern are passed through unchanged.
+func transformDate2Unix(v string) string {
+	m := dateRe.FindStringSubmatch(v)
+	if m == nil {
+		return v
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", m[1]+" "+m[2])
+	if err != nil {
+		return v
+	}
+	return strconv.FormatInt(t.UnixMicro(), 10)
+}
+
+// djb2Hash8 returns the djb2 hash of v as 8 hex digits.
+func djb2Hash8(v string) string {
+	var h uint32 = 5381
+	for i := 0; i < len(v); i++ {
+		h = ((h << 5) + h) + uint32(v[i])
+	}
+	return fmt.Sprintf("%08x", h)
+}
+
+// simpleTransforms are the built-in -x/--transform names that take no
+// arguments beyond the value itself.
+var simpleTransforms = map[string]func(string) string{
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"trim":      strings.TrimSpace,
+	"basename":  filepath.Base,
+	"dirname":   filepath.Dir,
+	"hex":       func(v string) string { return hex.EncodeToString([]byte(v)) },
+	"md5":       func(v string) string { sum := md5.Sum([]byte(v)); return hex.EncodeToString(sum[:]) },
+	"hash8":     djb2Hash8,
+	"djb2":      djb2Hash8,
+	"date2unix": transformDate2Unix,
+}
+
+// parseRegexTransform parses a sed-style "s/pattern/replacement/" expression
+// into a function that applies it.
+func parseRegexTransform(expr string) (func(string) string, error) {
+	if len(expr) < 3 || expr[0] != 's' {
+		return nil, fmt.Errorf("regex transform must look like s/pattern/replacement/, got %q", expr)
+	}
+	delim := string(expr[1])
+	parts := strings.SplitN(expr[2:], delim, 3)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("regex transform must look like s/pattern/replacement/, got %q", expr)
+	}
+
+	re, err := regexp.Compile(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %v", parts[0], err)
+	}
+	replacement := parts[1]
+	return func(v string) string {
+		return re.ReplaceAllString(v, replacement)
+	}, nil
+}
+
+// parseTransformSpec parses a single -x/--transform argument, e.g. "2:upper"
+// or "4:regex:s/foo/bar/", into the 1-indexed column it applies to and the
+// function to apply.
+func parseTransformSpec(spec string) (int, func(string) string, error) {
+	sep := strings.IndexByte(spec, ':')
+	if sep <= 0 {
+		return 0, nil, fmt.Errorf("could not parse %q as a column:transform expression", spec)
+	}
+
+	col, err := strconv.Atoi(spec[:sep])
+	if err != nil || col < 1 {
+		return 0, nil, fmt.Errorf("could not parse %q as a column:transform expression: column must be a positive number", spec)
+	}
+
+	rest := spec[sep+1:]
+	if name := strings.TrimPrefix(rest, "regex:"); name != rest {
+		apply, err := parseRegexTransform(name)
+		if err != nil {
+			return 0, nil, fmt.Errorf("could not parse %q: %v", spec, err)
+		}
+		return col, apply, nil
+	}
+
+	apply, ok := simpleTransforms[rest]
+	if !ok {
+		return 0, nil, fmt.Errorf("unknown transform %q in %q", rest, spec)
+	}
+	return col, apply, nil
+}
+
+// aggregator accumulates the running results of --count/--sum/--min/--max/
+// --uniq across the whole stream, to be printed once at EOF instead of a row
+// per input row.
+type aggregator struct {
+	showCount  bool
+	count      int64
+	sumCol     int
+	sum        float64
+	minCol     int
+	min        float64
+	haveMin    bool
+	maxCol     int
+	max        float64
+	haveMax    bool
+	uniq       bool
+	uniqSeen   map[string]bool
+	uniqValues []string
+}
+
+// add folds one row into the aggregator. row is the 1-indexed field values
+// of the row (post-transform); uniqKey is the already-joined string of the
+// row's selected output columns.
+func (a *aggregator) add(row []string, uniqKey string) {
+	a.count++
+
+	if a.sumCol > 0 && a.sumCol <= len(row) {
+		if f, err := strconv.ParseFloat(row[a.sumCol-1], 64); err == nil {
+			a.sum += f
+		}
+	}
+	if a.minCol > 0 && a.minCol <= len(row) {
+		if f, err := strconv.ParseFloat(row[a.minCol-1], 64); err == nil {
+			if !a.haveMin || f < a.min {
+				a.min, a.haveMin = f, true
+			}
+		}
+	}
+	if a.maxCol > 0 && a.maxCol <= len(row) {
+		if f, err := strconv.ParseFloat(row[a.maxCol-1], 64); err == nil {
+			if !a.haveMax || f > a.max {
+				a.max, a.haveMax = f, true
+			}
+		}
+	}
+	if a.uniq && !a.uniqSeen[uniqKey] {
+		a.uniqSeen[uniqKey] = true
+		a.uniqValues = append(a.uniqValues, uniqKey)
+	}
+}
+
+// print writes the requested summary lines to w.
+func (a *aggregator) print(w io.Writer) {
+	for _, v := range a.uniqValues {
+		fmt.Fprintln(w, v)
+	}
+	if a.showCount {
+		fmt.Fprintf(w, "count: %d\n", a.count)
+	}
+	if a.sumCol > 0 {
+		fmt.Fprintf(w, "sum: %v\n", a.sum)
+	}
+	if a.minCol > 0 {
+		fmt.Fprintf(w, "min: %v\n", a.min)
+	}
+	if a.maxCol > 0 {
+		fmt.Fprintf(w, "max: %v\n", a.max)
+	}
+}
+
+// reorderArgs rearranges command-line arguments so that every recognized
+// flag (and its value, if it takes one) comes before the positional column
+// arguments. The stdlib flag package stops parsing flags at the first
+// non-flag argument, so without this, flags given after column args (e.g.
+// "cl 1 2 -x 2:upper" or "cl --complement 1 2 -t") would be swallowed as
+// bogus column specs instead of being recognized. Arguments that look like
+// flags but aren't registered (e.g. the negative column index "-1") are left
+// as positional so they reach column parsing unchanged; when any positional
+// argument starts with "-", a "--" is placed before the positional arguments
+// so that flag parsing stops there instead of rejecting it.
+func reorderArgs(args []string) []string {
+	var flags, positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		hasValue := strings.ContainsRune(name, '=')
+		if hasValue {
+			name = name[:strings.IndexByte(name, '=')]
+		}
+
+		f := flag.Lookup(name)
+		if f == nil {
+			positional = append(positional, arg)
+			continue
+		}
+
+		flags = append(flags, arg)
+		if !hasValue {
+			if bv, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !bv.IsBoolFlag() {
+				if i+1 < len(args) {
+					i++
+					flags = append(flags, args[i])
+				}
+			}
+		}
+	}
+	for _, arg := range positional {
+		if len(arg) > 1 && arg[0] == '-' {
+			flags = append(flags, "--")
+			break
 		}
-		columns[c] = struct{}{}
 	}
+	return append(flags, positional...)
+}
 
-	if len(columns) == 0 {
+// run executes the command and returns a shell return code. It expects
+// flag.Parse to have already been called (by main, or by a test that wants
+// to control the arguments and io seams independently of the real process).
+func run() int {
+	// Figure out what columns the user wants and validate them
+	//
+
+	if len(flag.Args()) == 0 {
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	if !validFormats[inputFormat] {
+		fmt.Fprintf(stderr, "ERROR: unknown --input-format %q\n", inputFormat)
+		return 1
+	}
+	if !validFormats[outputFormat] {
+		fmt.Fprintf(stderr, "ERROR: unknown --output-format %q\n", outputFormat)
+		return 1
+	}
+
+	// Non-positional formats (json/ndjson) have no fixed column order, so
+	// selecting their fields by key/dotted-path is always enabled, regardless
+	// of -H. Numeric indexes still select by position in the sorted key list.
+	selector, err := newColumnSelector(flag.Args(), complement, headerMode || !isPositionalFormat(inputFormat))
+	if err != nil {
+		fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
 	// Figure out how to separate columns
 	//
 
@@ -143,6 +1031,37 @@ func run() int {
 		separator = "\t"
 	}
 
+	if !isPositionalFormat(inputFormat) && (separator != "" || useTabSeparator) {
+		fmt.Fprintf(stderr, "ERROR: -s/-t cannot be combined with --input-format %s\n", inputFormat)
+		return 1
+	}
+	if widthsSpec != "" {
+		if inputFormat != "auto" && inputFormat != "whitespace" {
+			fmt.Fprintf(stderr, "ERROR: -w cannot be combined with --input-format %s\n", inputFormat)
+			return 1
+		}
+		if separator != "" || useTabSeparator {
+			fmt.Fprintf(stderr, "ERROR: -w cannot be combined with -s/-t\n")
+			return 1
+		}
+	}
+
+	usesRecordSplitting := recordSeparator != "\n" || paragraphMode || maxRecordSize != 0
+	if usesRecordSplitting && (inputFormat == "csv" || inputFormat == "tsv" || inputFormat == "json") {
+		fmt.Fprintf(stderr, "ERROR: --record-separator/--paragraph/--max-record-size cannot be combined with --input-format %s\n", inputFormat)
+		return 1
+	}
+	if paragraphMode && recordSeparator != "\n" {
+		fmt.Fprintf(stderr, "ERROR: --paragraph cannot be combined with a custom --record-separator\n")
+		return 1
+	}
+
+	recordSplitFunc, err := newRecordSplitter(recordSeparator, paragraphMode)
+	if err != nil {
+		fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
 	var separatorRegex *regexp.Regexp
 	if separator != "" {
 		var err error
@@ -153,38 +1072,230 @@ func run() int {
 		}
 	}
 
-	// Scan and split
+	// Parse column transforms
 	//
-	firstRow := true
-	scanner := bufio.NewScanner(stdin)
-	for scanner.Scan() {
-		if firstRow && ignoreHeaderRow {
-			firstRow = false
-			continue
+
+	transforms := map[int]func(string) string{}
+	for _, spec := range transformArgs {
+		col, fn, err := parseTransformSpec(spec)
+		if err != nil {
+			fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		transforms[col] = fn
+	}
+
+	// Parse aggregations
+	//
+
+	var agg *aggregator
+	if countAgg || sumCol != "" || minCol != "" || maxCol != "" || uniqAgg {
+		agg = &aggregator{showCount: countAgg, uniq: uniqAgg, uniqSeen: map[string]bool{}}
+		for _, pair := range []struct {
+			name string
+			spec string
+			dest *int
+		}{
+			{"--sum", sumCol, &agg.sumCol},
+			{"--min", minCol, &agg.minCol},
+			{"--max", maxCol, &agg.maxCol},
+		} {
+			if pair.spec == "" {
+				continue
+			}
+			col, err := strconv.Atoi(pair.spec)
+			if err != nil || col < 1 {
+				fmt.Fprintf(stderr, "ERROR: %s must be a column number, got %q\n", pair.name, pair.spec)
+				return 1
+			}
+			*pair.dest = col
 		}
+	}
 
-		var fields []string
-		if separatorRegex == nil {
-			fields = strings.Fields(scanner.Text())
+	// Build the row source for the chosen input format
+	//
+
+	var source rowSource
+	switch {
+	case widthsSpec != "":
+		scanner := newScanner(stdin, recordSplitFunc, maxRecordSize)
+		var ranges []charRange
+		var firstLine string
+		hasFirstLine := false
+		if widthsSpec == "auto" {
+			if scanner.Scan() {
+				firstLine = scanner.Text()
+				hasFirstLine = true
+				ranges = detectWidths(firstLine)
+			}
 		} else {
-			fields = separatorRegex.Split(scanner.Text(), -1)
+			var err error
+			ranges, err = parseWidthSpec(widthsSpec)
+			if err != nil {
+				fmt.Fprintf(stderr, "ERROR: %v\n", err)
+				return 1
+			}
+		}
+		source = newWidthRowSource(scanner, ranges, firstLine, hasFirstLine)
+	case inputFormat == "csv" || inputFormat == "tsv":
+		r := csv.NewReader(stdin)
+		r.FieldsPerRecord = -1
+		if inputFormat == "tsv" {
+			r.Comma = '\t'
+		}
+		source = newCSVRowSource(r)
+	case inputFormat == "ndjson":
+		source = newNDJSONRowSource(newScanner(stdin, recordSplitFunc, maxRecordSize))
+	case inputFormat == "json":
+		var err error
+		source, err = newJSONRowSource(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "ERROR: %v\n", err)
+			return 1
+		}
+	default:
+		splitFields := func(line string) []string {
+			if separatorRegex == nil {
+				return strings.Fields(line)
+			}
+			return separatorRegex.Split(line, -1)
+		}
+		source = newDelimitedRowSource(newScanner(stdin, recordSplitFunc, maxRecordSize), splitFields)
+	}
+
+	// Build the emitter for the chosen output format
+	//
+
+	if outputFormat == "auto" {
+		outputFormat = "whitespace"
+	}
+
+	var csvWriter *csv.Writer
+	if outputFormat == "csv" || outputFormat == "tsv" {
+		csvWriter = csv.NewWriter(stdout)
+		if outputFormat == "tsv" {
+			csvWriter.Comma = '\t'
 		}
+	}
+	var jsonRows []map[string]string
+
+	emit := func(fields []string, indexes []int, headers []string) error {
+		switch outputFormat {
+		case "csv", "tsv":
+			record := make([]string, len(indexes))
+			for i, idx := range indexes {
+				record[i] = fields[idx-1]
+			}
+			return csvWriter.Write(record)
+		case "json":
+			jsonRows = append(jsonRows, rowObject(fields, indexes, headers))
+			return nil
+		case "ndjson":
+			b, err := json.Marshal(rowObject(fields, indexes, headers))
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(stdout, string(b))
+			return err
+		default:
+			printRow(fields, indexes)
+			return nil
+		}
+	}
 
-		printedFirstColumn := false
-		for i, f := range fields {
-			if _, exists := columns[int64(i+1)]; exists {
-				if printedFirstColumn {
-					fmt.Fprint(stdout, "\t")
+	// Read rows, resolve columns, and emit
+	//
+
+	var headers []string
+	firstRow := true
+	for {
+		fields, rowHeaders, ok, err := source()
+		if err != nil {
+			fmt.Fprintf(stderr, "ERROR: failed to read input: %v\n", err)
+			return 1
+		}
+		if !ok {
+			break
+		}
+
+		if firstRow {
+			firstRow = false
+			if headerMode && isPositionalFormat(inputFormat) {
+				headers = fields
+				// Aggregations produce a summary instead of per-row output, so
+				// the header row is only a normal output row when there's no
+				// aggregation to spoil, or the user explicitly asked for it.
+				if printHeaders || (!ignoreHeaderRow && agg == nil) {
+					if err := emit(fields, selector.Resolve(len(fields), headers), headers); err != nil {
+						fmt.Fprintf(stderr, "ERROR: failed to write output: %v\n", err)
+						return 1
+					}
 				}
-				printedFirstColumn = true
-				fmt.Fprint(stdout, f)
+				continue
+			}
+			if ignoreHeaderRow && isPositionalFormat(inputFormat) {
+				continue
+			}
+		}
+
+		for col, fn := range transforms {
+			if col >= 1 && col <= len(fields) {
+				fields[col-1] = fn(fields[col-1])
+			}
+		}
+
+		hdrs := headers
+		if rowHeaders != nil {
+			hdrs = rowHeaders
+		}
+		indexes := selector.Resolve(len(fields), hdrs)
+
+		if agg != nil {
+			selected := make([]string, len(indexes))
+			for i, idx := range indexes {
+				selected[i] = fields[idx-1]
 			}
+			agg.add(fields, strings.Join(selected, "\t"))
+			continue
+		}
+
+		if err := emit(fields, indexes, hdrs); err != nil {
+			fmt.Fprintf(stderr, "ERROR: failed to write output: %v\n", err)
+			return 1
 		}
-		fmt.Fprint(stdout, "\n")
 	}
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(stderr, "ERROR: failed to read input: %v\n", err)
-		return 1
+
+	if agg != nil {
+		agg.print(stdout)
+		return 0
 	}
+
+	switch outputFormat {
+	case "csv", "tsv":
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			fmt.Fprintf(stderr, "ERROR: failed to write output: %v\n", err)
+			return 1
+		}
+	case "json":
+		b, err := json.Marshal(jsonRows)
+		if err != nil {
+			fmt.Fprintf(stderr, "ERROR: failed to write output: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, string(b))
+	}
+
 	return 0
 }
+
+// printRow prints the selected fields of a row, tab-separated, to stdout.
+func printRow(fields []string, indexes []int) {
+	for i, idx := range indexes {
+		if i > 0 {
+			fmt.Fprint(stdout, "\t")
+		}
+		fmt.Fprint(stdout, fields[idx-1])
+	}
+	fmt.Fprint(stdout, "\n")
+}
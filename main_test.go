@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestParseColumnSpec(t *testing.T) {
+	tests := []struct {
+		name          string
+		arg           string
+		allowKeyMatch bool
+		want          columnSpec
+		wantErr       bool
+	}{
+		{name: "index", arg: "3", want: columnSpec{start: 3, end: 3}},
+		{name: "negative index", arg: "-1", want: columnSpec{start: -1, end: -1}},
+		{name: "range", arg: "2-5", want: columnSpec{start: 2, end: 5}},
+		{name: "open range", arg: "3-", want: columnSpec{start: 3, end: 0}},
+		{name: "zero is invalid", arg: "0", wantErr: true},
+		{name: "name without allowKeyMatch is invalid", arg: "PID", wantErr: true},
+		{name: "name with allowKeyMatch", arg: "PID", allowKeyMatch: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseColumnSpec(tt.arg, tt.allowKeyMatch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseColumnSpec(%q, %v) error = %v, wantErr %v", tt.arg, tt.allowKeyMatch, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.want.pattern == nil && got.pattern != nil {
+				return // name patterns are compiled regexes; just check they parsed
+			}
+			if got.start != tt.want.start || got.end != tt.want.end {
+				t.Errorf("parseColumnSpec(%q, %v) = %+v, want %+v", tt.arg, tt.allowKeyMatch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnSelectorResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		complement bool
+		rowLen     int
+		want       []int
+	}{
+		{name: "single column", args: []string{"2"}, rowLen: 3, want: []int{2}},
+		{name: "range", args: []string{"1-2"}, rowLen: 3, want: []int{1, 2}},
+		{name: "negative index", args: []string{"-1"}, rowLen: 3, want: []int{3}},
+		{name: "exclusion", args: []string{"^2"}, rowLen: 3, want: []int{1, 3}},
+		{name: "complement", args: []string{"2"}, complement: true, rowLen: 3, want: []int{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, err := newColumnSelector(tt.args, tt.complement, false)
+			if err != nil {
+				t.Fatalf("newColumnSelector(%v) error = %v", tt.args, err)
+			}
+			got := cs.Resolve(tt.rowLen, nil)
+			if !intSlicesEqual(got, tt.want) {
+				t.Errorf("Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func intSlicesEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFlattenJSON(t *testing.T) {
+	headers, fields := flattenJSON(map[string]interface{}{
+		"level": "info",
+		"user": map[string]interface{}{
+			"id":   float64(5),
+			"name": "alice",
+		},
+	})
+	wantHeaders := []string{"level", "user.id", "user.name"}
+	wantFields := []string{"info", "5", "alice"}
+	if !stringSlicesEqual(headers, wantHeaders) {
+		t.Errorf("headers = %v, want %v", headers, wantHeaders)
+	}
+	if !stringSlicesEqual(fields, wantFields) {
+		t.Errorf("fields = %v, want %v", fields, wantFields)
+	}
+}
+
+func TestFlattenJSONScalar(t *testing.T) {
+	headers, fields := flattenJSON("hello")
+	if headers != nil {
+		t.Errorf("headers = %v, want nil", headers)
+	}
+	if !stringSlicesEqual(fields, []string{"hello"}) {
+		t.Errorf("fields = %v, want [hello]", fields)
+	}
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSliceByWidths(t *testing.T) {
+	ranges := []charRange{{start: 0, end: 4}, {start: 4, end: -1}}
+	got := sliceByWidths("123 abcdef", ranges)
+	want := []string{"123", "abcdef"}
+	if !stringSlicesEqual(got, want) {
+		t.Errorf("sliceByWidths() = %v, want %v", got, want)
+	}
+}
+
+func TestSliceByWidthsOverflow(t *testing.T) {
+	ranges := []charRange{{start: 0, end: 4}, {start: 4, end: 10}}
+	got := sliceByWidths("ab", ranges)
+	want := []string{"ab", ""}
+	if !stringSlicesEqual(got, want) {
+		t.Errorf("sliceByWidths() = %v, want %v", got, want)
+	}
+}
+
+func TestNewRecordSplitterInvalidRegex(t *testing.T) {
+	if _, err := newRecordSplitter("(unclosed", false); err == nil {
+		t.Fatal("expected an error for an invalid regex, got nil")
+	}
+}
+
+func TestNewRecordSplitterDefault(t *testing.T) {
+	split, err := newRecordSplitter("\n", false)
+	if err != nil {
+		t.Fatalf("newRecordSplitter() error = %v", err)
+	}
+	advance, token, err := split([]byte("a\nb"), false)
+	if err != nil || advance != 2 || string(token) != "a" {
+		t.Errorf("split() = (%d, %q, %v), want (2, \"a\", nil)", advance, token, err)
+	}
+}
+
+func TestParseTransformSpec(t *testing.T) {
+	col, fn, err := parseTransformSpec("2:upper")
+	if err != nil {
+		t.Fatalf("parseTransformSpec() error = %v", err)
+	}
+	if col != 2 {
+		t.Errorf("col = %d, want 2", col)
+	}
+	if got := fn("abc"); got != "ABC" {
+		t.Errorf("fn(%q) = %q, want %q", "abc", got, "ABC")
+	}
+}
+
+func TestParseTransformSpecRegex(t *testing.T) {
+	_, fn, err := parseTransformSpec(`1:regex:s/foo/bar/`)
+	if err != nil {
+		t.Fatalf("parseTransformSpec() error = %v", err)
+	}
+	if got := fn("foobaz"); got != "barbaz" {
+		t.Errorf("fn(%q) = %q, want %q", "foobaz", got, "barbaz")
+	}
+}
+
+func TestParseTransformSpecErrors(t *testing.T) {
+	for _, spec := range []string{"nocol", "2:nosuchtransform", "1:regex:broken"} {
+		if _, _, err := parseTransformSpec(spec); err == nil {
+			t.Errorf("parseTransformSpec(%q) expected an error, got nil", spec)
+		}
+	}
+}
+
+func TestAggregatorAdd(t *testing.T) {
+	a := &aggregator{showCount: true, sumCol: 1, minCol: 1, maxCol: 1, uniq: true, uniqSeen: map[string]bool{}}
+	a.add([]string{"2"}, "2")
+	a.add([]string{"5"}, "5")
+	a.add([]string{"5"}, "5")
+
+	if a.count != 3 {
+		t.Errorf("count = %d, want 3", a.count)
+	}
+	if a.sum != 12 {
+		t.Errorf("sum = %v, want 12", a.sum)
+	}
+	if a.min != 2 {
+		t.Errorf("min = %v, want 2", a.min)
+	}
+	if a.max != 5 {
+		t.Errorf("max = %v, want 5", a.max)
+	}
+	if !stringSlicesEqual(a.uniqValues, []string{"2", "5"}) {
+		t.Errorf("uniqValues = %v, want [2 5]", a.uniqValues)
+	}
+}
+
+func TestReorderArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "flags after positional",
+			args: []string{"1", "2", "-x", "2:upper"},
+			want: []string{"-x", "2:upper", "1", "2"},
+		},
+		{
+			name: "negative column index is left positional",
+			args: []string{"-1", "-t"},
+			want: []string{"-t", "--", "-1"},
+		},
+		{
+			name: "already-ordered flags are untouched",
+			args: []string{"-H", "-w", "auto", "COMMAND"},
+			want: []string{"-H", "-w", "auto", "COMMAND"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reorderArgs(tt.args)
+			if !stringSlicesEqual(got, tt.want) {
+				t.Errorf("reorderArgs(%v) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+// runCL resets the package-level flags, runs run() against in with the given
+// arguments, and returns what it wrote to stdout/stderr and its exit code.
+func runCL(args []string, in string) (stdout_, stderr_ string, code int) {
+	separator = ""
+	useTabSeparator = false
+	ignoreHeaderRow = false
+	complement = false
+	headerMode = false
+	printHeaders = false
+	inputFormat = "auto"
+	outputFormat = "auto"
+	widthsSpec = ""
+	recordSeparator = "\n"
+	maxRecordSize = 0
+	paragraphMode = false
+	transformArgs = nil
+	countAgg = false
+	sumCol = ""
+	minCol = ""
+	maxCol = ""
+	uniqAgg = false
+
+	var outBuf, errBuf bytes.Buffer
+	stdin = strings.NewReader(in)
+	stdout = &outBuf
+	stderr = &errBuf
+
+	flag.CommandLine.Parse(reorderArgs(args))
+	code = run()
+	return outBuf.String(), errBuf.String(), code
+}
+
+func TestRunWhitespaceColumn(t *testing.T) {
+	out, _, code := runCL([]string{"2"}, "1 2 3\n4 5 6\n")
+	if code != 0 || out != "2\n5\n" {
+		t.Errorf("out = %q, code = %d, want %q, 0", out, code, "2\n5\n")
+	}
+}
+
+func TestRunHeaderByName(t *testing.T) {
+	out, _, code := runCL([]string{"-H", "-i", "b"}, "a b c\n1 2 3\n")
+	if code != 0 || out != "2\n" {
+		t.Errorf("out = %q, code = %d, want %q, 0", out, code, "2\n")
+	}
+}
+
+func TestRunNDJSONDottedPathWithoutHeaderMode(t *testing.T) {
+	out, _, code := runCL([]string{"--input-format", "ndjson", "user.id"}, `{"user":{"id":5},"level":"info"}`+"\n")
+	if code != 0 || out != "5\n" {
+		t.Errorf("out = %q, code = %d, want %q, 0", out, code, "5\n")
+	}
+}
+
+func TestRunTransformAfterPositionalArgs(t *testing.T) {
+	out, _, code := runCL([]string{"1", "-x", "1:upper"}, "abc\n")
+	if code != 0 || out != "ABC\n" {
+		t.Errorf("out = %q, code = %d, want %q, 0", out, code, "ABC\n")
+	}
+}
+
+func TestRunSumAggregation(t *testing.T) {
+	out, _, code := runCL([]string{"1", "--sum", "1", "--count"}, "2\n3\n")
+	want := "count: 2\nsum: 5\n"
+	if code != 0 || out != want {
+		t.Errorf("out = %q, code = %d, want %q, 0", out, code, want)
+	}
+}
+
+func TestRunInvalidRecordSeparatorReportsError(t *testing.T) {
+	_, errOut, code := runCL([]string{"1", "--record-separator", "(unclosed"}, "a\n")
+	if code != 1 || !strings.Contains(errOut, "ERROR:") {
+		t.Errorf("code = %d, errOut = %q, want 1 and an ERROR message", code, errOut)
+	}
+}
+
+func TestRunHeaderModeAggregationSuppressesHeaderRow(t *testing.T) {
+	out, _, code := runCL([]string{"-H", "name", "--sum", "2"}, "name amt\na 2\nb 3\n")
+	want := "sum: 5\n"
+	if code != 0 || out != want {
+		t.Errorf("out = %q, code = %d, want %q, 0", out, code, want)
+	}
+}
+
+func TestRunHeaderModeAggregationWithPrintHeaders(t *testing.T) {
+	out, _, code := runCL([]string{"-H", "name", "--sum", "2", "--print-headers"}, "name amt\na 2\nb 3\n")
+	want := "name\nsum: 5\n"
+	if code != 0 || out != want {
+		t.Errorf("out = %q, code = %d, want %q, 0", out, code, want)
+	}
+}